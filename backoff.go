@@ -0,0 +1,54 @@
+package qrpc
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffConfig controls the truncated exponential backoff used between
+// reconnect attempts, matching gRPC's connection backoff recurrence:
+// delay = min(MaxDelay, BaseDelay * Multiplier^retries), jittered by a
+// uniform sample in [1-Jitter, 1+Jitter].
+type BackoffConfig struct {
+	BaseDelay  time.Duration
+	Multiplier float64
+	Jitter     float64
+	MaxDelay   time.Duration
+}
+
+// DefaultBackoffConfig mirrors gRPC's default connection backoff.
+var DefaultBackoffConfig = BackoffConfig{
+	BaseDelay:  1.0 * time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   120 * time.Second,
+}
+
+// backoff returns the delay to wait before the (retries+1)th reconnect
+// attempt, with retries==0 meaning "the first retry after an initial
+// failure".
+func (c BackoffConfig) backoff(retries int) time.Duration {
+	if retries == 0 {
+		return c.jitter(c.BaseDelay)
+	}
+	base := float64(c.BaseDelay)
+	max := float64(c.MaxDelay)
+	delay := base
+	for i := 0; i < retries && delay < max; i++ {
+		delay *= c.Multiplier
+	}
+	if delay > max {
+		delay = max
+	}
+	return c.jitter(time.Duration(delay))
+}
+
+func (c BackoffConfig) jitter(d time.Duration) time.Duration {
+	if c.Jitter <= 0 {
+		return d
+	}
+	delta := c.Jitter * float64(d)
+	min := float64(d) - delta
+	max := float64(d) + delta
+	return time.Duration(min + (max-min)*rand.Float64())
+}
@@ -0,0 +1,51 @@
+package qrpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffConfigGrowsAndCapsAtMaxDelay(t *testing.T) {
+	c := BackoffConfig{
+		BaseDelay:  100 * time.Millisecond,
+		Multiplier: 2,
+		Jitter:     0, // deterministic
+		MaxDelay:   time.Second,
+	}
+
+	got := c.backoff(0)
+	if got != 100*time.Millisecond {
+		t.Fatalf("backoff(0) = %v, want BaseDelay unjittered (100ms)", got)
+	}
+
+	got = c.backoff(1)
+	if got != 200*time.Millisecond {
+		t.Fatalf("backoff(1) = %v, want 200ms", got)
+	}
+
+	got = c.backoff(2)
+	if got != 400*time.Millisecond {
+		t.Fatalf("backoff(2) = %v, want 400ms", got)
+	}
+
+	// 100ms * 2^10 would be ~102s, far past MaxDelay.
+	if got := c.backoff(10); got != time.Second {
+		t.Fatalf("backoff(10) = %v, want capped at MaxDelay (1s)", got)
+	}
+}
+
+func TestBackoffConfigJitterStaysInRange(t *testing.T) {
+	c := BackoffConfig{
+		BaseDelay:  time.Second,
+		Multiplier: 1.6,
+		Jitter:     0.2,
+		MaxDelay:   time.Minute,
+	}
+
+	for i := 0; i < 100; i++ {
+		d := c.backoff(0)
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("backoff(0) = %v, want within +/-20%% of BaseDelay (1s)", d)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package qrpc
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoSubConnAvailable is returned by Balancer.Pick when no subconn is
+// currently READY.
+var ErrNoSubConnAvailable = errors.New("qrpc: no ready subconn available")
+
+// Balancer picks a SubConn to send a request on, mirroring gRPC's
+// balancer.Balancer. ClientConn calls UpdateSubConns whenever the set of
+// known addresses or a subconn's ConnectivityState changes.
+type Balancer interface {
+	// Pick returns the SubConn to use for r.
+	Pick(r *RequestFrame) (*SubConn, error)
+	// UpdateSubConns is called by the owning ClientConn with the
+	// current, full set of subconns whenever it changes.
+	UpdateSubConns(subconns []*SubConn)
+}
+
+// pickfirstBalancer always picks the first READY subconn in the list it
+// was last given, matching gRPC's pick_first.
+type pickfirstBalancer struct {
+	mu       sync.Mutex
+	subconns []*SubConn
+}
+
+// NewPickFirstBalancer returns a Balancer that picks the first READY
+// subconn in the order it was last given by UpdateSubConns.
+func NewPickFirstBalancer() Balancer { return &pickfirstBalancer{} }
+
+func (b *pickfirstBalancer) UpdateSubConns(subconns []*SubConn) {
+	b.mu.Lock()
+	b.subconns = subconns
+	b.mu.Unlock()
+}
+
+func (b *pickfirstBalancer) Pick(r *RequestFrame) (*SubConn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sc := range b.subconns {
+		if sc.State() == Ready {
+			return sc, nil
+		}
+	}
+	return nil, ErrNoSubConnAvailable
+}
+
+// roundrobinBalancer cycles through the READY subconns in order,
+// spreading load evenly across them.
+type roundrobinBalancer struct {
+	mu       sync.Mutex
+	subconns []*SubConn
+	next     uint32
+}
+
+// NewRoundRobinBalancer returns a Balancer that distributes requests
+// evenly across all READY subconns.
+func NewRoundRobinBalancer() Balancer { return &roundrobinBalancer{} }
+
+func (b *roundrobinBalancer) UpdateSubConns(subconns []*SubConn) {
+	b.mu.Lock()
+	b.subconns = subconns
+	b.mu.Unlock()
+}
+
+func (b *roundrobinBalancer) Pick(r *RequestFrame) (*SubConn, error) {
+	b.mu.Lock()
+	subconns := b.subconns
+	b.mu.Unlock()
+
+	ready := make([]*SubConn, 0, len(subconns))
+	for _, sc := range subconns {
+		if sc.State() == Ready {
+			ready = append(ready, sc)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, ErrNoSubConnAvailable
+	}
+	next := atomic.AddUint32(&b.next, 1)
+	return ready[next%uint32(len(ready))], nil
+}
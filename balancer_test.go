@@ -0,0 +1,66 @@
+package qrpc
+
+import "testing"
+
+func subConnWithState(s ConnectivityState) *SubConn {
+	sc := &SubConn{}
+	sc.state = s
+	return sc
+}
+
+func TestPickFirstBalancerPicksFirstReady(t *testing.T) {
+	b := NewPickFirstBalancer()
+	a := subConnWithState(TransientFailure)
+	ready := subConnWithState(Ready)
+	c := subConnWithState(Ready)
+	b.UpdateSubConns([]*SubConn{a, ready, c})
+
+	got, err := b.Pick(nil)
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	if got != ready {
+		t.Fatalf("Pick returned %p, want the first READY subconn %p", got, ready)
+	}
+}
+
+func TestPickFirstBalancerNoneReady(t *testing.T) {
+	b := NewPickFirstBalancer()
+	b.UpdateSubConns([]*SubConn{subConnWithState(TransientFailure), subConnWithState(Draining)})
+
+	if _, err := b.Pick(nil); err != ErrNoSubConnAvailable {
+		t.Fatalf("Pick() error = %v, want ErrNoSubConnAvailable", err)
+	}
+}
+
+func TestRoundRobinBalancerSkipsNonReady(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	ready1 := subConnWithState(Ready)
+	ready2 := subConnWithState(Ready)
+	b.UpdateSubConns([]*SubConn{ready1, subConnWithState(TransientFailure), ready2})
+
+	seen := map[*SubConn]bool{}
+	for i := 0; i < 10; i++ {
+		got, err := b.Pick(nil)
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		seen[got] = true
+	}
+
+	if !seen[ready1] || !seen[ready2] {
+		t.Fatalf("round robin never picked both READY subconns over 10 picks: seen=%v", seen)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("round robin picked %d distinct subconns, want exactly the 2 READY ones", len(seen))
+	}
+}
+
+func TestRoundRobinBalancerNoneReady(t *testing.T) {
+	b := NewRoundRobinBalancer()
+	b.UpdateSubConns([]*SubConn{subConnWithState(TransientFailure)})
+
+	if _, err := b.Pick(nil); err != ErrNoSubConnAvailable {
+		t.Fatalf("Pick() error = %v, want ErrNoSubConnAvailable", err)
+	}
+}
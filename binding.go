@@ -0,0 +1,135 @@
+package qrpc
+
+import "time"
+
+// Metric is the narrow interface qrpc needs from a metrics library
+// (e.g. a prometheus HistogramVec) to record per-request latency.
+type Metric interface {
+	With(labelValues ...string) Observer
+}
+
+// Observer records a single observation, e.g. a request latency in seconds.
+type Observer interface {
+	Observe(v float64)
+}
+
+// ServerBinding binds a Handler to a listen address, together with the
+// knobs that control how connections accepted on that address behave.
+type ServerBinding struct {
+	Addr    string
+	Handler Handler
+
+	// DefaultReadTimeout and DefaultWriteTimeout are in seconds, 0 means no timeout
+	DefaultReadTimeout  int
+	DefaultWriteTimeout int
+
+	// MaxFrameSize is the max size for each request frame on this binding.
+	// DefaultMaxFrameSize is used when zero.
+	MaxFrameSize int
+
+	// LatencyMetric, when set, is used to record per method request latency.
+	LatencyMetric Metric
+
+	// MaxUploadBufferPerConnection is the connection-level flow control
+	// window, analogous to http2.Server.MaxUploadBufferPerConnection.
+	// DefaultConnWindowSize is used when zero. Values are clamped to
+	// [MinFlowControlWindowSize, MaxFlowControlWindowSize].
+	MaxUploadBufferPerConnection int32
+
+	// MaxUploadBufferPerStream is the per-stream flow control window,
+	// analogous to http2.Server.MaxUploadBufferPerStream.
+	// DefaultStreamWindowSize is used when zero. Values are clamped to
+	// [MinFlowControlWindowSize, MaxFlowControlWindowSize].
+	MaxUploadBufferPerStream int32
+
+	// NewWriteScheduler, when set, constructs the WriteScheduler used to
+	// order outgoing frames on each connection accepted by this binding.
+	// newFIFOWriteScheduler is used when nil: queue order, with RST
+	// frames moved ahead of queued data.
+	NewWriteScheduler func() WriteScheduler
+
+	// UnaryInterceptors run, in order, around every blocking handler
+	// dispatched on connections accepted by this binding. The chain is
+	// built once per connection, not once per request.
+	UnaryInterceptors []UnaryInterceptor
+
+	// StreamInterceptors is UnaryInterceptors' counterpart for requests
+	// with the non-blocking flag set.
+	StreamInterceptors []StreamInterceptor
+
+	// IdleTimeout closes a connection that has read or written no
+	// frames for this long. Zero means no idle timeout, matching
+	// net/http's Server.IdleTimeout.
+	IdleTimeout time.Duration
+
+	// KeepAliveInterval, when non-zero, sends a PING control frame on
+	// this cadence and expects a matching PONG within KeepAliveTimeout.
+	KeepAliveInterval time.Duration
+	// KeepAliveTimeout is how long to wait for a PONG before closing the
+	// connection. DefaultKeepAliveTimeout is used when zero and
+	// KeepAliveInterval is set.
+	KeepAliveTimeout time.Duration
+
+	// MaxConnectionAge, when non-zero, starts a graceful drain once a
+	// connection has been open this long: no new RequestIDs are
+	// accepted, in-flight handlers get MaxConnectionAgeGrace to finish,
+	// then the connection is closed.
+	MaxConnectionAge time.Duration
+	// MaxConnectionAgeGrace bounds the drain started by MaxConnectionAge.
+	// DefaultMaxConnectionAgeGrace is used when zero and MaxConnectionAge
+	// is set.
+	MaxConnectionAgeGrace time.Duration
+
+	// RTTMetric, when set, records the observed PING/PONG round-trip
+	// time in seconds for connections on this binding.
+	RTTMetric Metric
+}
+
+// DefaultKeepAliveTimeout and DefaultMaxConnectionAgeGrace are used when
+// the corresponding ServerBinding field is left zero.
+const (
+	DefaultKeepAliveTimeout      = 20 * time.Second
+	DefaultMaxConnectionAgeGrace = 10 * time.Second
+)
+
+func (b *ServerBinding) keepAliveTimeout() time.Duration {
+	if b.KeepAliveTimeout > 0 {
+		return b.KeepAliveTimeout
+	}
+	return DefaultKeepAliveTimeout
+}
+
+func (b *ServerBinding) maxConnectionAgeGrace() time.Duration {
+	if b.MaxConnectionAgeGrace > 0 {
+		return b.MaxConnectionAgeGrace
+	}
+	return DefaultMaxConnectionAgeGrace
+}
+
+func (b *ServerBinding) newWriteScheduler() WriteScheduler {
+	if b.NewWriteScheduler != nil {
+		return b.NewWriteScheduler()
+	}
+	return newFIFOWriteScheduler()
+}
+
+func (b *ServerBinding) connWindowSize() int32 {
+	return clampWindowSize(b.MaxUploadBufferPerConnection, DefaultConnWindowSize)
+}
+
+func (b *ServerBinding) streamWindowSize() int32 {
+	return clampWindowSize(b.MaxUploadBufferPerStream, DefaultStreamWindowSize)
+}
+
+func clampWindowSize(n int32, def int32) int32 {
+	if n == 0 {
+		n = def
+	}
+	if n < MinFlowControlWindowSize {
+		return MinFlowControlWindowSize
+	}
+	if n > MaxFlowControlWindowSize {
+		return MaxFlowControlWindowSize
+	}
+	return n
+}
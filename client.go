@@ -0,0 +1,384 @@
+package qrpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnectivityState is the state of a SubConn, mirroring gRPC's
+// connectivity.State.
+type ConnectivityState int32
+
+const (
+	Idle ConnectivityState = iota
+	Connecting
+	Ready
+	// Draining means the subconn got a GOAWAY from the server: the
+	// underlying connection is still up and serving requests already in
+	// flight, but it must not be picked for new ones. It has no gRPC
+	// counterpart since qrpc's Balancers only gate picking on Ready.
+	Draining
+	TransientFailure
+	Shutdown
+)
+
+func (s ConnectivityState) String() string {
+	switch s {
+	case Idle:
+		return "IDLE"
+	case Connecting:
+		return "CONNECTING"
+	case Ready:
+		return "READY"
+	case Draining:
+		return "DRAINING"
+	case TransientFailure:
+		return "TRANSIENT_FAILURE"
+	case Shutdown:
+		return "SHUTDOWN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// DialOption configures a ClientConn created by Dial.
+type DialOption func(*dialOptions)
+
+type dialOptions struct {
+	resolver Resolver
+	balancer Balancer
+	backoff  BackoffConfig
+	handler  Handler
+}
+
+// WithResolver overrides the default static resolver.
+func WithResolver(r Resolver) DialOption {
+	return func(o *dialOptions) { o.resolver = r }
+}
+
+// WithBalancer overrides the default pick-first balancer.
+func WithBalancer(b Balancer) DialOption {
+	return func(o *dialOptions) { o.balancer = b }
+}
+
+// WithBackoffConfig overrides DefaultBackoffConfig for reconnects.
+func WithBackoffConfig(c BackoffConfig) DialOption {
+	return func(o *dialOptions) { o.backoff = c }
+}
+
+// WithHandler sets the Handler invoked for frames pushed by the server
+// on any subconn (e.g. server-initiated pushes, analogous to
+// Server.PushFrame on the server side).
+func WithHandler(h Handler) DialOption {
+	return func(o *dialOptions) { o.handler = h }
+}
+
+// ClientConn maintains one SubConn per address resolved for target,
+// reconnecting failed ones with truncated exponential backoff, and
+// dispatches outgoing requests through a Balancer. It is qrpc's
+// counterpart to gRPC's ClientConn.
+type ClientConn struct {
+	target  string
+	opts    dialOptions
+	handler Handler
+
+	mu       sync.Mutex
+	subconns map[string]*SubConn // keyed by Address.Addr
+
+	closeCh chan struct{}
+
+	// watchCtx bounds the resolver's Watch goroutine to this ClientConn's
+	// lifetime; watchCancel is called from Close so an abandoned watch
+	// (e.g. dnsResolver's polling goroutine) doesn't leak past it.
+	watchCtx    context.Context
+	watchCancel context.CancelFunc
+}
+
+// Dial creates a ClientConn for target and starts resolving and
+// connecting to its addresses in the background. By default it uses a
+// static resolver around target itself (treating it as a single
+// host:port) and a pick-first balancer.
+func Dial(target string, opts ...DialOption) (*ClientConn, error) {
+	o := dialOptions{
+		balancer: NewPickFirstBalancer(),
+		backoff:  DefaultBackoffConfig,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.resolver == nil {
+		o.resolver = NewStaticResolver(Address{Addr: target})
+	}
+
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	cc := &ClientConn{
+		target:      target,
+		opts:        o,
+		handler:     o.handler,
+		subconns:    make(map[string]*SubConn),
+		closeCh:     make(chan struct{}),
+		watchCtx:    watchCtx,
+		watchCancel: watchCancel,
+	}
+
+	addrs, err := o.resolver.Resolve(target)
+	if err != nil {
+		watchCancel()
+		return nil, err
+	}
+	cc.updateAddresses(addrs)
+
+	watch, err := o.resolver.Watch(watchCtx, target)
+	if err == nil {
+		go cc.watchResolver(watch)
+	}
+
+	return cc, nil
+}
+
+func (cc *ClientConn) watchResolver(watch <-chan []Address) {
+	for {
+		select {
+		case addrs, ok := <-watch:
+			if !ok {
+				return
+			}
+			cc.updateAddresses(addrs)
+		case <-cc.closeCh:
+			return
+		}
+	}
+}
+
+// updateAddresses reconciles the live subconn set with addrs: new
+// addresses get a SubConn that starts connecting immediately, addresses
+// no longer present are torn down.
+func (cc *ClientConn) updateAddresses(addrs []Address) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	seen := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		seen[a.Addr] = true
+		if _, ok := cc.subconns[a.Addr]; ok {
+			continue
+		}
+		sc := newSubConn(cc, a)
+		cc.subconns[a.Addr] = sc
+		go sc.connectLoop()
+	}
+	for addr, sc := range cc.subconns {
+		if !seen[addr] {
+			sc.shutdown()
+			delete(cc.subconns, addr)
+		}
+	}
+	cc.notifyBalancerLocked()
+}
+
+func (cc *ClientConn) notifyBalancerLocked() {
+	subconns := make([]*SubConn, 0, len(cc.subconns))
+	for _, sc := range cc.subconns {
+		subconns = append(subconns, sc)
+	}
+	cc.opts.balancer.UpdateSubConns(subconns)
+}
+
+// Pick selects a SubConn for r using the configured Balancer.
+func (cc *ClientConn) Pick(r *RequestFrame) (*SubConn, error) {
+	return cc.opts.balancer.Pick(r)
+}
+
+// Close tears down every subconn and stops watching the resolver.
+func (cc *ClientConn) Close() error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	select {
+	case <-cc.closeCh:
+		return nil
+	default:
+		close(cc.closeCh)
+	}
+	cc.watchCancel()
+	for _, sc := range cc.subconns {
+		sc.shutdown()
+	}
+	return nil
+}
+
+// SubConn is one dialed connection to a single resolved Address. It owns
+// a reconnect loop that retries with truncated exponential backoff and
+// jitter on failure, and exposes ConnectivityState transitions so
+// callers can build their own health logic on top via
+// WaitForStateChange.
+type SubConn struct {
+	cc   *ClientConn
+	addr Address
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	state   ConnectivityState
+	retries int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeFrameCh chan writeFrameRequest
+}
+
+func newSubConn(cc *ClientConn, addr Address) *SubConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := &SubConn{
+		cc:           cc,
+		addr:         addr,
+		ctx:          ctx,
+		cancel:       cancel,
+		writeFrameCh: make(chan writeFrameRequest),
+	}
+	sc.cond = sync.NewCond(&sc.mu)
+	return sc
+}
+
+// State returns the SubConn's current ConnectivityState.
+func (sc *SubConn) State() ConnectivityState {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.state
+}
+
+// WaitForStateChange blocks until State() differs from sourceState or
+// ctx is done, returning false in the latter case.
+func (sc *SubConn) WaitForStateChange(ctx context.Context, sourceState ConnectivityState) bool {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			sc.mu.Lock()
+			sc.cond.Broadcast()
+			sc.mu.Unlock()
+		case <-done:
+		}
+	}()
+	defer close(done)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for sc.state == sourceState {
+		if ctx.Err() != nil {
+			return false
+		}
+		sc.cond.Wait()
+	}
+	return ctx.Err() == nil
+}
+
+func (sc *SubConn) setState(s ConnectivityState) {
+	sc.mu.Lock()
+	sc.state = s
+	sc.mu.Unlock()
+	sc.cond.Broadcast()
+}
+
+// GetWriter returns a FrameWriter that sends requests over this subconn.
+// Only valid while State() == Ready.
+func (sc *SubConn) GetWriter() FrameWriter {
+	return newFrameWriter(sc.ctx, sc.writeFrameCh)
+}
+
+func (sc *SubConn) shutdown() {
+	sc.setState(Shutdown)
+	sc.cancel()
+}
+
+// connectLoop dials addr, reconnecting with truncated exponential
+// backoff and jitter after every failure or dropped connection, resetting
+// the retry count once a connection becomes READY.
+func (sc *SubConn) connectLoop() {
+	backoff := sc.cc.opts.backoff
+	for {
+		if sc.ctx.Err() != nil {
+			return
+		}
+
+		sc.setState(Connecting)
+		conn, err := net.DialTimeout("tcp", sc.addr.Addr, 5*time.Second)
+		if err != nil {
+			sc.setState(TransientFailure)
+			if !sc.sleepBackoff(backoff) {
+				return
+			}
+			continue
+		}
+
+		sc.retries = 0
+		sc.setState(Ready)
+		sc.serve(conn) // blocks until the connection drops
+
+		if sc.ctx.Err() != nil {
+			return
+		}
+		sc.setState(TransientFailure)
+		if !sc.sleepBackoff(backoff) {
+			return
+		}
+	}
+}
+
+func (sc *SubConn) sleepBackoff(backoff BackoffConfig) bool {
+	delay := backoff.backoff(sc.retries)
+	sc.retries++
+	select {
+	case <-time.After(delay):
+		return true
+	case <-sc.ctx.Done():
+		return false
+	}
+}
+
+// serve reads and writes frames on conn until it errors out or the
+// SubConn is shut down, mirroring serveconn's split read/write loops.
+func (sc *SubConn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		reader := newFrameReaderWithMFS(sc.ctx, conn, 0, DefaultMaxFrameSize)
+		for {
+			req, err := reader.ReadFrame(nil)
+			if err != nil {
+				return
+			}
+			frame := (*RequestFrame)(req)
+			if frame.Flags.IsGoAway() {
+				// Server is draining: stop picking this subconn for new
+				// requests, but keep serving until it closes the
+				// connection (or we're shut down), so replies already in
+				// flight still land.
+				sc.setState(Draining)
+				continue
+			}
+			if sc.cc.handler != nil {
+				sc.cc.handler.ServeQRPC(sc.GetWriter(), frame)
+			}
+		}
+	}()
+
+	writer := NewWriterWithTimeout(sc.ctx, conn, 0)
+	for {
+		select {
+		case res := <-sc.writeFrameCh:
+			_, err := writer.Write(res.dfw.GetWbuf())
+			res.result <- err
+			if err != nil {
+				return
+			}
+		case <-readerDone:
+			return
+		case <-sc.ctx.Done():
+			return
+		}
+	}
+}
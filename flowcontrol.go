@@ -0,0 +1,140 @@
+package qrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// Flow control window bounds, mirroring the HTTP/2 spec's
+// SETTINGS_INITIAL_WINDOW_SIZE limits.
+const (
+	MinFlowControlWindowSize = 65535     // 2^16-1, the HTTP/2 minimum
+	MaxFlowControlWindowSize = 1<<31 - 1 // the HTTP/2 maximum
+	DefaultStreamWindowSize  = 1 << 20   // 1MiB
+	DefaultConnWindowSize    = 16 << 20  // 16MiB
+)
+
+// WindowUpdateFlag marks a frame as a flow control credit announcement
+// rather than a data-carrying request/response. The payload is a single
+// big-endian uint32 counting the number of bytes the sender may now add
+// back to its send window for the RequestID the frame carries.
+const WindowUpdateFlag PacketFlag = 1 << 7
+
+// IsWindowUpdate reports whether flags marks a flow control credit frame.
+func (flags PacketFlag) IsWindowUpdate() bool {
+	return flags&WindowUpdateFlag != 0
+}
+
+// ErrFlowControlViolation is returned when a peer sends more bytes on a
+// stream or connection than the advertised window allows.
+var ErrFlowControlViolation = errors.New("qrpc: flow control window exceeded")
+
+// flowControlExempt reports whether flags marks a frame that must never
+// block behind the connection/stream send window: RST, WINDOW_UPDATE,
+// PING/PONG and GOAWAY all carry a handful of protocol bytes rather than
+// flow-controlled application data, and writeFrames is the connection's
+// only writer, so blocking it on one of these behind a stalled peer
+// would also wedge the keepalive/idle watchdog and GOAWAY delivery that
+// are supposed to detect and react to exactly that peer.
+func flowControlExempt(flags PacketFlag) bool {
+	return flags.IsRst() || flags.IsWindowUpdate() || flags.IsPing() || flags.IsPong() || flags.IsGoAway()
+}
+
+func encodeWindowUpdate(n int32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+func decodeWindowUpdate(payload []byte) int32 {
+	if len(payload) < 4 {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(payload))
+}
+
+// flowWindow tracks an HTTP/2-style flow control window: a non-negative
+// credit balance that shrinks as bytes are sent/received and grows as
+// WINDOW_UPDATEs arrive or are issued. It is used both for the receive
+// side (bytes we still allow the peer to send us) and the send side
+// (bytes we're still allowed to send the peer).
+type flowWindow struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	avail  int32
+	closed bool
+}
+
+func newFlowWindow(initial int32) *flowWindow {
+	w := &flowWindow{avail: initial}
+	w.cond = sync.NewCond(&w.mu)
+	return w
+}
+
+// Consume accounts for n bytes received against the window. It returns
+// ErrFlowControlViolation if the peer sent more than it was allowed to.
+func (w *flowWindow) Consume(n int32) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n > w.avail {
+		return ErrFlowControlViolation
+	}
+	w.avail -= n
+	return nil
+}
+
+// Increase grows the window by n, e.g. on a WINDOW_UPDATE from the peer
+// or when the local side frees up buffer space, and wakes any writer
+// parked in Take.
+func (w *flowWindow) Increase(n int32) {
+	if n <= 0 {
+		return
+	}
+	w.mu.Lock()
+	w.avail += n
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// Take blocks until the window has credit available, then debits and
+// returns min(n, available). It returns 0 if the window was closed
+// while waiting.
+func (w *flowWindow) Take(n int32) int32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.avail <= 0 && !w.closed {
+		w.cond.Wait()
+	}
+	if w.closed {
+		return 0
+	}
+	if n > w.avail {
+		n = w.avail
+	}
+	w.avail -= n
+	return n
+}
+
+// Close unblocks any goroutine parked in Take, used when the owning
+// connection or stream goes away.
+func (w *flowWindow) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// acquireWindow blocks until n bytes of credit have been debited from w,
+// in possibly several installments as WINDOW_UPDATEs trickle in. It
+// returns false if w was closed before the full amount could be taken.
+func acquireWindow(w *flowWindow, n int32) bool {
+	for taken := int32(0); taken < n; {
+		got := w.Take(n - taken)
+		if got == 0 {
+			return false
+		}
+		taken += got
+	}
+	return true
+}
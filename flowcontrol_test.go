@@ -0,0 +1,50 @@
+package qrpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlowWindowCloseUnblocksTake(t *testing.T) {
+	w := newFlowWindow(0)
+
+	done := make(chan int32, 1)
+	go func() {
+		done <- w.Take(10)
+	}()
+
+	w.Close()
+
+	select {
+	case got := <-done:
+		if got != 0 {
+			t.Fatalf("Take after Close returned %d, want 0", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Take did not return after window was closed, goroutine leaked")
+	}
+}
+
+func TestFlowWindowConsumeViolation(t *testing.T) {
+	w := newFlowWindow(10)
+
+	if err := w.Consume(5); err != nil {
+		t.Fatalf("Consume(5) on a 10-byte window: %v", err)
+	}
+	if err := w.Consume(6); err != ErrFlowControlViolation {
+		t.Fatalf("Consume(6) on a 5-byte remaining window = %v, want ErrFlowControlViolation", err)
+	}
+}
+
+func TestFlowControlExempt(t *testing.T) {
+	exempt := []PacketFlag{StreamRstFlag, WindowUpdateFlag, PingFlag, PongFlag, GoAwayFlag}
+	for _, f := range exempt {
+		if !flowControlExempt(f) {
+			t.Errorf("flowControlExempt(%v) = false, want true", f)
+		}
+	}
+
+	if flowControlExempt(PacketFlag(0)) {
+		t.Error("flowControlExempt(0) = true, want false for a plain data frame")
+	}
+}
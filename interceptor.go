@@ -0,0 +1,180 @@
+package qrpc
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// UnaryInterceptor wraps the blocking request/response path, mirroring
+// gRPC's UnaryServerInterceptor. An interceptor must call next.ServeQRPC
+// to continue the chain; not calling it drops the request.
+type UnaryInterceptor func(ctx context.Context, w FrameWriter, r *RequestFrame, next Handler)
+
+// StreamInterceptor wraps the non-blocking (IsNonBlock) request path,
+// where the handler runs on its own goroutine. It has the same shape as
+// UnaryInterceptor; the two are kept as distinct types so a binding can
+// apply different cross-cutting concerns to each path.
+type StreamInterceptor func(ctx context.Context, w FrameWriter, r *RequestFrame, next Handler)
+
+// chainUnaryInterceptors composes ics around final into a single Handler.
+// serve() calls it once per connection; ServeMux calls it on each request
+// it dispatches.
+func chainUnaryInterceptors(ctx context.Context, ics []UnaryInterceptor, final Handler) Handler {
+	if len(ics) == 0 {
+		return final
+	}
+	return HandlerFunc(func(w FrameWriter, r *RequestFrame) {
+		var run func(i int, w FrameWriter, r *RequestFrame)
+		run = func(i int, w FrameWriter, r *RequestFrame) {
+			if i == len(ics) {
+				final.ServeQRPC(w, r)
+				return
+			}
+			ics[i](ctx, w, r, HandlerFunc(func(w FrameWriter, r *RequestFrame) {
+				run(i+1, w, r)
+			}))
+		}
+		run(0, w, r)
+	})
+}
+
+// chainStreamInterceptors is chainUnaryInterceptors' counterpart for the
+// non-blocking path.
+func chainStreamInterceptors(ctx context.Context, ics []StreamInterceptor, final Handler) Handler {
+	if len(ics) == 0 {
+		return final
+	}
+	return HandlerFunc(func(w FrameWriter, r *RequestFrame) {
+		var run func(i int, w FrameWriter, r *RequestFrame)
+		run = func(i int, w FrameWriter, r *RequestFrame) {
+			if i == len(ics) {
+				final.ServeQRPC(w, r)
+				return
+			}
+			ics[i](ctx, w, r, HandlerFunc(func(w FrameWriter, r *RequestFrame) {
+				run(i+1, w, r)
+			}))
+		}
+		run(0, w, r)
+	})
+}
+
+// RecoveryInterceptor recovers a panic from the rest of the chain,
+// logs it and RSTs the stream, folding the same behavior serve() has
+// always applied via handleRequestPanic. Use it when composing a custom
+// UnaryInterceptors chain that should keep that safety net.
+func RecoveryInterceptor() UnaryInterceptor {
+	return func(ctx context.Context, w FrameWriter, r *RequestFrame, next Handler) {
+		defer func() {
+			if err := recover(); err != nil {
+				const size = 64 << 10
+				buf := make([]byte, size)
+				buf = buf[:runtime.Stack(buf, false)]
+				logError("RecoveryInterceptor", err, string(buf))
+
+				s := r.Stream
+				if s == nil || !s.IsSelfClosed() {
+					w.StartWrite(r.RequestID, 0, StreamRstFlag)
+					if err := w.EndWrite(); err != nil {
+						logError("RecoveryInterceptor send rst", err, r.RequestID)
+					}
+				}
+			}
+		}()
+		next.ServeQRPC(w, r)
+	}
+}
+
+// NewLatencyInterceptor records per-method request latency to m, folding
+// the behavior of serveconn.instrument.
+func NewLatencyInterceptor(m Metric) UnaryInterceptor {
+	return func(ctx context.Context, w FrameWriter, r *RequestFrame, next Handler) {
+		begin := time.Now()
+		var err interface{}
+		defer func() {
+			err = recover()
+			lvs := []string{"method", fmt.Sprint(int(r.Cmd)), "error", fmt.Sprintf("%v", err)}
+			m.With(lvs...).Observe(time.Since(begin).Seconds())
+			if err != nil {
+				panic(err) // let RecoveryInterceptor (or the caller) handle it
+			}
+		}()
+		next.ServeQRPC(w, r)
+	}
+}
+
+// DeadlineFlag marks a RequestFrame as carrying a client-requested
+// timeout: the first 4 bytes of Payload are a big-endian uint32 deadline
+// in milliseconds, as read by DeadlineInterceptor.
+const DeadlineFlag PacketFlag = 1 << 6
+
+// IsDeadline reports whether flags marks a frame as carrying a
+// client-requested timeout, see DeadlineFlag.
+func (flags PacketFlag) IsDeadline() bool {
+	return flags&DeadlineFlag != 0
+}
+
+func deadlineFromFrame(r *RequestFrame) (time.Duration, bool) {
+	if !r.Flags.IsDeadline() || len(r.Payload) < 4 {
+		return 0, false
+	}
+	ms := decodeWindowUpdate(r.Payload[:4])
+	if ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// DeadlineInterceptor reads a timeout from DeadlineFlag/Payload and RSTs
+// the stream if the handler hasn't finished by then, so a slow handler
+// can't hold a stream open past what the client asked for.
+func DeadlineInterceptor() UnaryInterceptor {
+	return func(ctx context.Context, w FrameWriter, r *RequestFrame, next Handler) {
+		timeout, ok := deadlineFromFrame(r)
+		if !ok {
+			next.ServeQRPC(w, r)
+			return
+		}
+
+		// r.Payload carries the 4-byte deadline ahead of the actual
+		// application payload; strip it so next sees the same bytes it
+		// would without DeadlineFlag set. The full payload is put back
+		// once next returns, so the flow control window is replenished
+		// by every byte the frame consumed.
+		payload := r.Payload
+		r.Payload = r.Payload[4:]
+		defer func() { r.Payload = payload }()
+
+		dctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			next.ServeQRPC(w, r)
+		}()
+
+		select {
+		case <-done:
+		case <-dctx.Done():
+			// w is still owned by the handler goroutine above; get our
+			// own writer rather than racing it on the same FrameWriter.
+			rstWriter := w
+			if sc := connFromContext(ctx); sc != nil {
+				rstWriter = sc.GetWriter()
+			}
+			rstWriter.StartWrite(r.RequestID, 0, StreamRstFlag)
+			if err := rstWriter.EndWrite(); err != nil {
+				logError("DeadlineInterceptor send rst", err, r.RequestID)
+			}
+			// Don't return while next is still running: the caller
+			// (serve()'s blocking branch) calls readMore() right after
+			// this interceptor returns, which would let the reader
+			// reuse/invalidate r and w out from under the still-running
+			// handler goroutine.
+			<-done
+		}
+	}
+}
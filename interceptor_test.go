@@ -0,0 +1,78 @@
+package qrpc
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeFrameWriter is a minimal FrameWriter for exercising interceptors
+// without a real connection.
+type fakeFrameWriter struct {
+	started []PacketFlag
+	ended   int
+}
+
+func (w *fakeFrameWriter) StartWrite(requestID uint64, cmd Cmd, flags PacketFlag) {
+	w.started = append(w.started, flags)
+}
+
+func (w *fakeFrameWriter) WriteBytes(v []byte) {}
+
+func (w *fakeFrameWriter) EndWrite() error {
+	w.ended++
+	return nil
+}
+
+func TestChainUnaryInterceptorsRunsInOrder(t *testing.T) {
+	var trace []string
+	mark := func(name string) UnaryInterceptor {
+		return func(ctx context.Context, w FrameWriter, r *RequestFrame, next Handler) {
+			trace = append(trace, name+":before")
+			next.ServeQRPC(w, r)
+			trace = append(trace, name+":after")
+		}
+	}
+
+	final := HandlerFunc(func(w FrameWriter, r *RequestFrame) {
+		trace = append(trace, "final")
+	})
+
+	h := chainUnaryInterceptors(context.Background(), []UnaryInterceptor{mark("a"), mark("b")}, final)
+	h.ServeQRPC(&fakeFrameWriter{}, &RequestFrame{RequestID: 1})
+
+	want := []string{"a:before", "b:before", "final", "b:after", "a:after"}
+	if len(trace) != len(want) {
+		t.Fatalf("trace = %v, want %v", trace, want)
+	}
+	for i := range want {
+		if trace[i] != want[i] {
+			t.Fatalf("trace = %v, want %v", trace, want)
+		}
+	}
+}
+
+func TestRecoveryInterceptorRstsOnPanicAndStopsIt(t *testing.T) {
+	ic := RecoveryInterceptor()
+	w := &fakeFrameWriter{}
+	r := &RequestFrame{RequestID: 7}
+
+	panicking := HandlerFunc(func(w FrameWriter, r *RequestFrame) {
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Fatalf("panic escaped RecoveryInterceptor: %v", p)
+			}
+		}()
+		ic(context.Background(), w, r, panicking)
+	}()
+
+	if w.ended != 1 {
+		t.Fatalf("EndWrite called %d times, want 1 (the RST frame)", w.ended)
+	}
+	if len(w.started) != 1 || !w.started[0].IsRst() {
+		t.Fatalf("started frames = %v, want a single RST", w.started)
+	}
+}
@@ -0,0 +1,201 @@
+package qrpc
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+)
+
+// PingFlag marks a zero-payload-or-nonce control frame sent to check
+// that a connection is alive, mirroring yamux/HTTP2 PING. The peer
+// replies with the same nonce on a PongFlag frame.
+const PingFlag PacketFlag = 1 << 5
+
+// PongFlag marks the reply to a PingFlag frame.
+const PongFlag PacketFlag = 1 << 4
+
+// IsPing reports whether flags marks a keepalive ping.
+func (flags PacketFlag) IsPing() bool { return flags&PingFlag != 0 }
+
+// IsPong reports whether flags marks a keepalive pong.
+func (flags PacketFlag) IsPong() bool { return flags&PongFlag != 0 }
+
+func encodeNonce(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func decodeNonce(payload []byte) int64 {
+	if len(payload) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(payload))
+}
+
+// touchActivity records that a frame was just read or written, for
+// IdleTimeout bookkeeping.
+func (sc *serveconn) touchActivity() {
+	atomic.StoreInt64(&sc.lastActivity, time.Now().UnixNano())
+}
+
+func (sc *serveconn) idleFor() time.Duration {
+	last := atomic.LoadInt64(&sc.lastActivity)
+	return time.Since(time.Unix(0, last))
+}
+
+// isDraining reports whether the connection has started a
+// MaxConnectionAge drain and should no longer accept new RequestIDs.
+func (sc *serveconn) isDraining() bool {
+	return atomic.LoadInt32(&sc.draining) != 0
+}
+
+func (sc *serveconn) startDraining() {
+	atomic.StoreInt32(&sc.draining, 1)
+	// Nothing left to drain: close now instead of waiting for a request
+	// that's never coming to trip requestDone's check.
+	if sc.drainComplete() {
+		sc.Close()
+	}
+}
+
+func (sc *serveconn) registerPingWaiter(nonce int64, ch chan struct{}) {
+	sc.pingMu.Lock()
+	if sc.pingers == nil {
+		sc.pingers = make(map[int64]chan struct{})
+	}
+	sc.pingers[nonce] = ch
+	sc.pingMu.Unlock()
+}
+
+func (sc *serveconn) forgetPingWaiter(nonce int64) {
+	sc.pingMu.Lock()
+	delete(sc.pingers, nonce)
+	sc.pingMu.Unlock()
+}
+
+// resolvePong wakes up the keepaliveLoop waiting on nonce, if any.
+func (sc *serveconn) resolvePong(nonce int64) {
+	sc.pingMu.Lock()
+	ch, ok := sc.pingers[nonce]
+	delete(sc.pingers, nonce)
+	sc.pingMu.Unlock()
+	if ok {
+		ch <- struct{}{}
+	}
+}
+
+// sendPing writes a PING control frame carrying nonce.
+func (sc *serveconn) sendPing(nonce int64) error {
+	w := sc.GetWriter()
+	w.StartWrite(0, 0, PingFlag)
+	w.WriteBytes(encodeNonce(nonce))
+	return w.EndWrite()
+}
+
+// sendPong replies to a received PING, echoing its nonce.
+func (sc *serveconn) sendPong(nonce int64) error {
+	w := sc.GetWriter()
+	w.StartWrite(0, 0, PongFlag)
+	w.WriteBytes(encodeNonce(nonce))
+	return w.EndWrite()
+}
+
+// keepaliveLoop enforces IdleTimeout, sends periodic PINGs and enforces
+// KeepAliveTimeout, and starts/enforces the MaxConnectionAge drain. It
+// runs for the lifetime of the connection and exits when ctx is done.
+func (sc *serveconn) keepaliveLoop(binding *ServerBinding, opened time.Time) {
+	if binding.IdleTimeout <= 0 && binding.KeepAliveInterval <= 0 && binding.MaxConnectionAge <= 0 {
+		return
+	}
+
+	ctx := sc.ctx
+	var idleTicker, keepaliveTicker, ageTimer *time.Timer
+	defer func() {
+		for _, t := range []*time.Timer{idleTicker, keepaliveTicker, ageTimer} {
+			if t != nil {
+				t.Stop()
+			}
+		}
+	}()
+
+	if binding.IdleTimeout > 0 {
+		idleTicker = time.NewTimer(binding.IdleTimeout)
+	}
+	if binding.KeepAliveInterval > 0 {
+		keepaliveTicker = time.NewTimer(binding.KeepAliveInterval)
+	}
+	if binding.MaxConnectionAge > 0 {
+		ageTimer = time.NewTimer(time.Until(opened.Add(binding.MaxConnectionAge)))
+	}
+
+	idleCh := func() <-chan time.Time {
+		if idleTicker == nil {
+			return nil
+		}
+		return idleTicker.C
+	}
+	keepaliveCh := func() <-chan time.Time {
+		if keepaliveTicker == nil {
+			return nil
+		}
+		return keepaliveTicker.C
+	}
+
+	ageFired := false
+	for {
+		var ageCh <-chan time.Time
+		if ageTimer != nil && !ageFired {
+			ageCh = ageTimer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-idleCh():
+			if sc.idleFor() >= binding.IdleTimeout {
+				logError("connection idle timeout", sc.rwc.RemoteAddr().String())
+				sc.Close()
+				return
+			}
+			idleTicker.Reset(binding.IdleTimeout - sc.idleFor())
+		case <-keepaliveCh():
+			nonce := time.Now().UnixNano()
+			pongCh := make(chan struct{}, 1)
+			sc.registerPingWaiter(nonce, pongCh)
+			sentAt := time.Now()
+			if err := sc.sendPing(nonce); err != nil {
+				sc.forgetPingWaiter(nonce)
+				sc.Close()
+				return
+			}
+			select {
+			case <-pongCh:
+				if binding.RTTMetric != nil {
+					binding.RTTMetric.With().Observe(time.Since(sentAt).Seconds())
+				}
+			case <-time.After(binding.keepAliveTimeout()):
+				sc.forgetPingWaiter(nonce)
+				logError("keepalive timeout", sc.rwc.RemoteAddr().String())
+				sc.Close()
+				return
+			case <-ctx.Done():
+				return
+			}
+			keepaliveTicker.Reset(binding.KeepAliveInterval)
+		case <-ageCh:
+			// start draining: stop accepting new RequestIDs and give
+			// in-flight handlers MaxConnectionAgeGrace to finish.
+			ageFired = true
+			sc.startDraining()
+			grace := binding.maxConnectionAgeGrace()
+			go func() {
+				select {
+				case <-time.After(grace):
+					sc.Close()
+				case <-ctx.Done():
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package qrpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTouchActivityResetsIdleFor(t *testing.T) {
+	sc := &serveconn{}
+
+	if sc.idleFor() < time.Second {
+		t.Fatal("idleFor before any activity should be large (lastActivity is the zero time)")
+	}
+
+	sc.touchActivity()
+	if d := sc.idleFor(); d < 0 || d > time.Second {
+		t.Fatalf("idleFor() right after touchActivity = %v, want near 0", d)
+	}
+}
+
+func TestResolvePongWakesRegisteredWaiter(t *testing.T) {
+	sc := &serveconn{}
+	nonce := int64(42)
+	ch := make(chan struct{}, 1)
+	sc.registerPingWaiter(nonce, ch)
+
+	sc.resolvePong(nonce)
+
+	select {
+	case <-ch:
+	default:
+		t.Fatal("resolvePong did not wake the waiter registered for nonce")
+	}
+}
+
+func TestForgetPingWaiterStopsResolvePong(t *testing.T) {
+	sc := &serveconn{}
+	nonce := int64(7)
+	ch := make(chan struct{}, 1)
+	sc.registerPingWaiter(nonce, ch)
+	sc.forgetPingWaiter(nonce)
+
+	// Must not block or panic: nothing is listening for this nonce
+	// anymore, so resolvePong should just be a no-op.
+	sc.resolvePong(nonce)
+
+	select {
+	case <-ch:
+		t.Fatal("resolvePong sent to a waiter that was forgotten")
+	default:
+	}
+}
+
+func TestStartDrainingSetsIsDraining(t *testing.T) {
+	sc := &serveconn{}
+	if sc.isDraining() {
+		t.Fatal("isDraining true before startDraining was ever called")
+	}
+	// inFlight > 0 so startDraining's drainComplete check doesn't try to
+	// Close a serveconn with no server/rwc wired up.
+	sc.inFlight = 1
+	sc.startDraining()
+	if !sc.isDraining() {
+		t.Fatal("isDraining false after startDraining")
+	}
+}
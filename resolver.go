@@ -0,0 +1,106 @@
+package qrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Address is a single resolved endpoint a ClientConn can dial.
+type Address struct {
+	Addr string // host:port, passed directly to net.Dial("tcp", Addr)
+}
+
+// Resolver turns a logical target name into the set of Addresses that
+// serve it, mirroring gRPC's resolver.Resolver. Implementations must be
+// safe for concurrent use.
+type Resolver interface {
+	// Resolve returns the current set of addresses for target.
+	Resolve(target string) ([]Address, error)
+	// Watch returns a channel of address-set updates for target. The
+	// watch runs until ctx is done, at which point the channel is
+	// closed and any goroutine backing it exits; callers must cancel
+	// ctx once they stop reading from the channel or the watch leaks.
+	Watch(ctx context.Context, target string) (<-chan []Address, error)
+}
+
+// staticResolver resolves a target to a fixed, caller-supplied address
+// list; Watch never emits further updates.
+type staticResolver struct {
+	addrs []Address
+}
+
+// NewStaticResolver returns a Resolver that always resolves to addrs,
+// for targets whose membership is known up front (e.g. from config).
+func NewStaticResolver(addrs ...Address) Resolver {
+	return &staticResolver{addrs: addrs}
+}
+
+func (r *staticResolver) Resolve(target string) ([]Address, error) {
+	return r.addrs, nil
+}
+
+func (r *staticResolver) Watch(ctx context.Context, target string) (<-chan []Address, error) {
+	ch := make(chan []Address)
+	close(ch)
+	return ch, nil
+}
+
+// dnsResolver resolves target as a host:port pair, re-resolving the host
+// to its current set of A/AAAA records.
+type dnsResolver struct {
+	interval time.Duration
+}
+
+// NewDNSResolver returns a Resolver that polls DNS for target's current
+// addresses every interval.
+func NewDNSResolver(interval time.Duration) Resolver {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &dnsResolver{interval: interval}
+}
+
+func (r *dnsResolver) Resolve(target string) ([]Address, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, fmt.Errorf("qrpc: invalid dns target %q: %w", target, err)
+	}
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]Address, len(ips))
+	for i, ip := range ips {
+		addrs[i] = Address{Addr: net.JoinHostPort(ip, port)}
+	}
+	return addrs, nil
+}
+
+func (r *dnsResolver) Watch(ctx context.Context, target string) (<-chan []Address, error) {
+	ch := make(chan []Address)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			addrs, err := r.Resolve(target)
+			if err != nil {
+				logError("dns resolver", target, err)
+				continue
+			}
+			select {
+			case ch <- addrs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,57 @@
+package qrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStaticResolverWatchClosesImmediately(t *testing.T) {
+	r := NewStaticResolver(Address{Addr: "10.0.0.1:1234"})
+
+	addrs, err := r.Resolve("ignored")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0].Addr != "10.0.0.1:1234" {
+		t.Fatalf("Resolve() = %v, want the configured address", addrs)
+	}
+
+	ch, err := r.Watch(context.Background(), "ignored")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("staticResolver.Watch sent an update, want an immediately closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("staticResolver.Watch's channel was never closed")
+	}
+}
+
+func TestDNSResolverWatchStopsOnCancel(t *testing.T) {
+	r := NewDNSResolver(time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := r.Watch(ctx, "localhost:1234")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			// A poll may have already been in flight when ctx was
+			// canceled; drain until the channel closes.
+			for ok {
+				_, ok = <-ch
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dnsResolver.Watch's channel was not closed after ctx was canceled, goroutine leaked")
+	}
+}
@@ -0,0 +1,245 @@
+package qrpc
+
+import "sync"
+
+// WriteScheduler decides the order in which queued frames are written to
+// the wire for a single connection. It replaces the old single FIFO
+// channel so that, e.g., a long streaming response on one RequestID
+// cannot starve short RPCs sharing the same connection.
+//
+// Implementations must be safe for concurrent use: Push is called from
+// the connection's scheduleWrites goroutine (fed by FrameWriter.EndWrite),
+// Pop and CloseStream from its single writeFrames loop, and AdjustStream
+// from handler goroutines.
+type WriteScheduler interface {
+	// Push enqueues a frame to be written.
+	Push(frame *writeFrameRequest)
+	// Pop removes and returns the next frame to write, or ok==false if
+	// nothing is queued right now.
+	Pop() (frame *writeFrameRequest, ok bool)
+	// AdjustStream changes the relative weight used to interleave a
+	// stream's frames with others. Weight follows the HTTP/2 convention:
+	// higher weight gets proportionally more frames per round.
+	AdjustStream(id uint64, weight uint8)
+	// CloseStream discards any queued state for id, called once a
+	// stream is fully closed.
+	CloseStream(id uint64)
+}
+
+func controlStreamID(wr *writeFrameRequest) bool {
+	flags := wr.dfw.Flags()
+	return flags.IsRst()
+}
+
+// readySignaler is implemented by the built-in schedulers so writeFrames
+// can park until Push wakes it instead of busy-polling Pop.
+type readySignaler interface {
+	ready() <-chan struct{}
+}
+
+func (s *fifoWriteScheduler) ready() <-chan struct{} { return s.signal }
+
+func (s *roundRobinWriteScheduler) ready() <-chan struct{} { return s.signal }
+
+// newFIFOWriteScheduler returns a WriteScheduler close to qrpc's original
+// behavior: frames are written in the order they were queued, except
+// that RST frames are written ahead of any queued non-RST frames.
+func newFIFOWriteScheduler() WriteScheduler {
+	s := &fifoWriteScheduler{signal: make(chan struct{}, 1)}
+	return s
+}
+
+type fifoWriteScheduler struct {
+	mu     sync.Mutex
+	urgent []*writeFrameRequest
+	queue  []*writeFrameRequest
+	signal chan struct{}
+}
+
+func (s *fifoWriteScheduler) Push(frame *writeFrameRequest) {
+	s.mu.Lock()
+	if controlStreamID(frame) {
+		s.urgent = append(s.urgent, frame)
+	} else {
+		s.queue = append(s.queue, frame)
+	}
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *fifoWriteScheduler) wake() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *fifoWriteScheduler) Pop() (*writeFrameRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.urgent) > 0 {
+		frame := s.urgent[0]
+		s.urgent = s.urgent[1:]
+		return frame, true
+	}
+	if len(s.queue) > 0 {
+		frame := s.queue[0]
+		s.queue = s.queue[1:]
+		return frame, true
+	}
+	return nil, false
+}
+
+func (s *fifoWriteScheduler) AdjustStream(id uint64, weight uint8) {}
+
+func (s *fifoWriteScheduler) CloseStream(id uint64) {}
+
+// newRoundRobinWriteScheduler returns a WriteScheduler that fairly
+// interleaves frames queued for different RequestIDs: each stream gets
+// up to its weight's worth of frames per visit before control moves on
+// to the next stream, so one busy stream cannot starve the others.
+// RST/close frames always jump the queue so teardown stays prompt.
+func newRoundRobinWriteScheduler() WriteScheduler {
+	return &roundRobinWriteScheduler{
+		queues:  make(map[uint64]*streamWriteQueue),
+		pending: make(map[uint64]uint8),
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+type streamWriteQueue struct {
+	frames []*writeFrameRequest
+	weight uint8
+	credit int
+}
+
+const defaultStreamWeight uint8 = 16
+
+type roundRobinWriteScheduler struct {
+	mu     sync.Mutex
+	urgent []*writeFrameRequest
+	order  []uint64
+	queues map[uint64]*streamWriteQueue
+	pos    int
+	signal chan struct{}
+
+	// pending holds weights set by AdjustStream for a stream whose
+	// queue doesn't exist yet (AdjustStreamPriority's documented
+	// use case is setting priority before the stream's first Push),
+	// applied once Push creates the queue.
+	pending map[uint64]uint8
+}
+
+func (s *roundRobinWriteScheduler) Push(frame *writeFrameRequest) {
+	s.mu.Lock()
+	if controlStreamID(frame) {
+		s.urgent = append(s.urgent, frame)
+		s.mu.Unlock()
+		s.wake()
+		return
+	}
+
+	id := frame.dfw.RequestID()
+	q, ok := s.queues[id]
+	if !ok {
+		weight := defaultStreamWeight
+		if w, ok := s.pending[id]; ok {
+			weight = w
+			delete(s.pending, id)
+		}
+		q = &streamWriteQueue{weight: weight}
+		s.queues[id] = q
+		s.order = append(s.order, id)
+	}
+	q.frames = append(q.frames, frame)
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *roundRobinWriteScheduler) wake() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *roundRobinWriteScheduler) Pop() (*writeFrameRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.urgent) > 0 {
+		frame := s.urgent[0]
+		s.urgent = s.urgent[1:]
+		return frame, true
+	}
+
+	for i := 0; i < len(s.order); i++ {
+		if s.pos >= len(s.order) {
+			s.pos = 0
+		}
+		id := s.order[s.pos]
+		q := s.queues[id]
+
+		if len(q.frames) == 0 {
+			s.removeStreamLocked(id)
+			continue
+		}
+
+		if q.credit <= 0 {
+			q.credit = int(q.weight)
+		}
+
+		frame := q.frames[0]
+		q.frames = q.frames[1:]
+		q.credit--
+
+		if len(q.frames) == 0 {
+			// removeStreamLocked deletes s.order[s.pos] and shifts the
+			// rest left, so s.pos already points at the next stream in
+			// rotation; advancing it here would skip one.
+			s.removeStreamLocked(id)
+		} else if q.credit <= 0 {
+			s.pos++
+		}
+		return frame, true
+	}
+	return nil, false
+}
+
+// removeStreamLocked drops id's bookkeeping; callers must hold s.mu.
+func (s *roundRobinWriteScheduler) removeStreamLocked(id uint64) {
+	delete(s.queues, id)
+	for i, v := range s.order {
+		if v == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	if s.pos >= len(s.order) {
+		s.pos = 0
+	}
+}
+
+// AdjustStream sets id's weight for when its queue is created, so a
+// handler can call AdjustStreamPriority right after a stream opens,
+// before it has written anything, without the setting being silently
+// dropped by a race with the stream's first Push.
+func (s *roundRobinWriteScheduler) AdjustStream(id uint64, weight uint8) {
+	if weight == 0 {
+		weight = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q, ok := s.queues[id]; ok {
+		q.weight = weight
+		return
+	}
+	s.pending[id] = weight
+}
+
+func (s *roundRobinWriteScheduler) CloseStream(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+	s.removeStreamLocked(id)
+}
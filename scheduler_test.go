@@ -0,0 +1,86 @@
+package qrpc
+
+import "testing"
+
+// pushFrames seeds id's queue directly, bypassing Push (which needs a
+// real *defaultFrameWriter just to check IsRst) so the test can stay
+// focused on Pop's rotation bookkeeping.
+func pushFrames(s *roundRobinWriteScheduler, id uint64, n int) []*writeFrameRequest {
+	q, ok := s.queues[id]
+	if !ok {
+		q = &streamWriteQueue{weight: defaultStreamWeight}
+		s.queues[id] = q
+		s.order = append(s.order, id)
+	}
+	frames := make([]*writeFrameRequest, n)
+	for i := range frames {
+		frames[i] = &writeFrameRequest{}
+		q.frames = append(q.frames, frames[i])
+	}
+	return frames
+}
+
+func TestRoundRobinWriteSchedulerDoesNotSkipStreams(t *testing.T) {
+	s := newRoundRobinWriteScheduler().(*roundRobinWriteScheduler)
+
+	idOf := map[*writeFrameRequest]uint64{}
+	for _, f := range pushFrames(s, 1, 1) { // A
+		idOf[f] = 1
+	}
+	for _, f := range pushFrames(s, 2, 3) { // B
+		idOf[f] = 2
+	}
+	for _, f := range pushFrames(s, 3, 3) { // C
+		idOf[f] = 3
+	}
+
+	var order []uint64
+	for {
+		frame, ok := s.Pop()
+		if !ok {
+			break
+		}
+		order = append(order, idOf[frame])
+	}
+
+	seen := map[uint64]int{}
+	for _, id := range order {
+		seen[id]++
+	}
+	if seen[1] != 1 || seen[2] != 3 || seen[3] != 3 {
+		t.Fatalf("frame counts per stream = %v, want A:1 B:3 C:3", seen)
+	}
+
+	// B must not be starved until C fully drains: B's first frame has
+	// to come out before C's last one.
+	firstB, lastC := -1, -1
+	for i, id := range order {
+		if id == 2 && firstB == -1 {
+			firstB = i
+		}
+		if id == 3 {
+			lastC = i
+		}
+	}
+	if firstB > lastC {
+		t.Fatalf("stream B starved until C drained: order=%v", order)
+	}
+}
+
+func TestRoundRobinWriteSchedulerAdjustStreamBeforeFirstPush(t *testing.T) {
+	s := newRoundRobinWriteScheduler().(*roundRobinWriteScheduler)
+
+	s.AdjustStream(1, 5)
+	pushFrames(s, 1, 1)
+
+	q, ok := s.queues[1]
+	if !ok {
+		t.Fatal("stream 1 has no queue after its first push")
+	}
+	if q.weight != 5 {
+		t.Fatalf("queue weight = %d, want 5 from the AdjustStream call that preceded Push", q.weight)
+	}
+	if _, ok := s.pending[1]; ok {
+		t.Fatal("pending weight for stream 1 was not cleared once its queue was created")
+	}
+}
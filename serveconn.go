@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -47,6 +48,31 @@ type serveconn struct {
 	readFrameCh  chan readFrameResult   // written by conn.readFrames
 	writeFrameCh chan writeFrameRequest // written by FrameWriter
 
+	// flow control, see flowcontrol.go. connRecvWindow/connSendWindow
+	// bound the whole connection; the per-stream windows are created
+	// lazily as streams are first seen and torn down with the stream.
+	connRecvWindow *flowWindow
+	connSendWindow *flowWindow
+
+	flowMu           sync.Mutex
+	streamRecvWindow map[uint64]*flowWindow
+	streamSendWindow map[uint64]*flowWindow
+
+	scheduler WriteScheduler // used by writeFrames, fed from writeFrameCh
+
+	// keepalive/idle/max-age bookkeeping, see keepalive.go.
+	lastActivity int64 // unix nano, atomic
+	draining     int32 // atomic bool, set once MaxConnectionAge drain starts
+
+	// inFlight counts RequestFrames currently dispatched to a handler
+	// (unary or streaming), so a drain can tell when it's safe to close
+	// the connection instead of waiting out Shutdown's ctx. Atomic.
+	inFlight int32
+
+	pingMu  sync.Mutex
+	pingers map[int64]chan struct{}
+
+	lastRequestID int64 // highest RequestID admitted for dispatch, atomic
 }
 
 // ConnectionInfoKey is context key for ConnectionInfo
@@ -59,6 +85,18 @@ type ConnectionInfo struct {
 	Anything interface{}
 }
 
+// connFromContext returns the *serveconn serve() stored in ctx, or nil if
+// ctx wasn't built by serve() (e.g. a test context). Interceptors use
+// this to get their own FrameWriter via GetWriter() rather than reusing
+// the non-reentrant one passed to the handler they wrap.
+func connFromContext(ctx context.Context) *serveconn {
+	ci, _ := ctx.Value(ConnectionInfoKey).(*ConnectionInfo)
+	if ci == nil {
+		return nil
+	}
+	return ci.SC
+}
+
 // Server returns the server
 func (sc *serveconn) Server() *Server {
 	return sc.server
@@ -110,14 +148,32 @@ func (sc *serveconn) serve(ctx context.Context) {
 	sc.reader = newFrameReaderWithMFS(ctx, sc.rwc, binding.DefaultReadTimeout, maxFrameSize)
 	sc.writer = newFrameWriter(ctx, sc.writeFrameCh) // only used by blocking mode
 
+	sc.connRecvWindow = newFlowWindow(binding.connWindowSize())
+	sc.connSendWindow = newFlowWindow(binding.connWindowSize())
+	sc.streamRecvWindow = make(map[uint64]*flowWindow)
+	sc.streamSendWindow = make(map[uint64]*flowWindow)
+	sc.scheduler = binding.newWriteScheduler()
+
+	opened := time.Now()
+	sc.touchActivity()
+
 	GoFunc(&sc.wg, func() {
 		sc.readFrames()
 	})
+	GoFunc(&sc.wg, func() {
+		sc.scheduleWrites()
+	})
+	GoFunc(&sc.wg, func() {
+		sc.keepaliveLoop(&binding, opened)
+	})
 	GoFunc(&sc.wg, func() {
 		sc.writeFrames(binding.DefaultWriteTimeout)
 	})
 
-	handler := binding.Handler
+	// the interceptor chains are built once for the lifetime of the
+	// connection instead of once per request.
+	unaryHandler := chainUnaryInterceptors(ctx, binding.UnaryInterceptors, binding.Handler)
+	streamHandler := chainStreamInterceptors(ctx, binding.StreamInterceptors, binding.Handler)
 
 	for {
 		select {
@@ -125,17 +181,34 @@ func (sc *serveconn) serve(ctx context.Context) {
 			return
 		case res := <-sc.readFrameCh:
 
+			if sc.isDraining() && sc.cs.GetStream(res.f.RequestID, res.f.Flags) == nil {
+				// MaxConnectionAge drain in progress: refuse new
+				// RequestIDs but let already-admitted streams continue.
+				sc.rstStream(res.f.RequestID, "draining, new request refused")
+				res.readMore()
+				continue
+			}
+
+			for id := int64(res.f.RequestID); ; {
+				cur := atomic.LoadInt64(&sc.lastRequestID)
+				if id <= cur || atomic.CompareAndSwapInt64(&sc.lastRequestID, cur, id) {
+					break
+				}
+			}
+
+			atomic.AddInt32(&sc.inFlight, 1)
+
 			if !res.f.Flags.IsNonBlock() {
 				func() {
 					defer sc.handleRequestPanic(res.f, time.Now())
-					handler.ServeQRPC(sc.writer, res.f)
+					unaryHandler.ServeQRPC(sc.writer, res.f)
 				}()
 				res.readMore()
 			} else {
 				res.readMore()
 				GoFunc(&sc.wg, func() {
 					defer sc.handleRequestPanic(res.f, time.Now())
-					handler.ServeQRPC(sc.GetWriter(), res.f)
+					streamHandler.ServeQRPC(sc.GetWriter(), res.f)
 				})
 			}
 		}
@@ -159,6 +232,7 @@ func (sc *serveconn) instrument(frame *RequestFrame, begin time.Time, err interf
 func (sc *serveconn) handleRequestPanic(frame *RequestFrame, begin time.Time) {
 	err := recover()
 	sc.instrument(frame, begin, err)
+	sc.replenishWindow(frame)
 
 	if err != nil {
 
@@ -180,6 +254,136 @@ func (sc *serveconn) handleRequestPanic(frame *RequestFrame, begin time.Time) {
 		}
 	}
 
+	// Either branch above leaves the stream closed (it already was, or
+	// the RST just forced it), so its flow control windows can go too.
+	sc.forgetStreamWindows(frame.RequestID)
+
+	sc.requestDone()
+}
+
+// requestDone marks one previously-counted inFlight request as finished.
+// Once a draining connection has none left, there's nothing more to wait
+// for, so it's closed right away instead of idling until Shutdown's ctx
+// expires or a hard close sweeps it up.
+func (sc *serveconn) requestDone() {
+	atomic.AddInt32(&sc.inFlight, -1)
+	if sc.drainComplete() {
+		sc.Close()
+	}
+}
+
+// drainComplete reports whether the connection is draining and has no
+// requests left in flight, i.e. there's nothing more for the drain to
+// wait on.
+func (sc *serveconn) drainComplete() bool {
+	return sc.isDraining() && atomic.LoadInt32(&sc.inFlight) == 0
+}
+
+// StreamCount returns the number of RequestFrames currently dispatched to
+// a handler on this connection, for ServerStats.
+func (sc *serveconn) StreamCount() int {
+	return int(atomic.LoadInt32(&sc.inFlight))
+}
+
+// lastProcessedRequestID returns the highest RequestID admitted for
+// dispatch so far, for inclusion in a GOAWAY frame.
+func (sc *serveconn) lastProcessedRequestID() uint64 {
+	return uint64(atomic.LoadInt64(&sc.lastRequestID))
+}
+
+// sendGoAway tells the peer to stop sending new requests on this
+// connection and that lastProcessedID is the highest RequestID it
+// should expect a response for; anything after that should be retried
+// elsewhere.
+func (sc *serveconn) sendGoAway(lastProcessedID uint64) error {
+	w := sc.GetWriter()
+	w.StartWrite(0, 0, GoAwayFlag)
+	w.WriteBytes(encodeNonce(int64(lastProcessedID)))
+	return w.EndWrite()
+}
+
+// rstStream logs reason, resets requestID and drops its flow control
+// windows. It is used when the sender overran its advertised flow
+// control window and when a draining connection refuses a new RequestID.
+func (sc *serveconn) rstStream(requestID uint64, reason string) {
+	logError(reason, sc.rwc.RemoteAddr().String(), requestID)
+	writer := sc.GetWriter()
+	writer.StartWrite(requestID, 0, StreamRstFlag)
+	if err := writer.EndWrite(); err != nil {
+		logError("send rst", err, sc.rwc.RemoteAddr().String(), requestID)
+	}
+	sc.forgetStreamWindows(requestID)
+}
+
+// recvWindowFor returns the per-stream receive window for requestID,
+// creating it with the binding's configured size on first use.
+func (sc *serveconn) recvWindowFor(requestID uint64) *flowWindow {
+	binding := sc.server.bindings[sc.idx]
+	sc.flowMu.Lock()
+	defer sc.flowMu.Unlock()
+	w, ok := sc.streamRecvWindow[requestID]
+	if !ok {
+		w = newFlowWindow(binding.streamWindowSize())
+		sc.streamRecvWindow[requestID] = w
+	}
+	return w
+}
+
+// sendWindowFor returns the per-stream send window for requestID,
+// creating it with the binding's configured size on first use.
+func (sc *serveconn) sendWindowFor(requestID uint64) *flowWindow {
+	binding := sc.server.bindings[sc.idx]
+	sc.flowMu.Lock()
+	defer sc.flowMu.Unlock()
+	w, ok := sc.streamSendWindow[requestID]
+	if !ok {
+		w = newFlowWindow(binding.streamWindowSize())
+		sc.streamSendWindow[requestID] = w
+	}
+	return w
+}
+
+// forgetStreamWindows drops the per-stream windows once a stream is
+// fully closed so long-lived connections don't leak bookkeeping.
+func (sc *serveconn) forgetStreamWindows(requestID uint64) {
+	sc.flowMu.Lock()
+	delete(sc.streamRecvWindow, requestID)
+	delete(sc.streamSendWindow, requestID)
+	sc.flowMu.Unlock()
+}
+
+// closeStreamWindows closes every still-tracked per-stream window so
+// any writeFrames goroutine parked in flowWindow.Take for one of them
+// wakes up instead of blocking forever past connection teardown.
+func (sc *serveconn) closeStreamWindows() {
+	sc.flowMu.Lock()
+	defer sc.flowMu.Unlock()
+	for _, w := range sc.streamRecvWindow {
+		w.Close()
+	}
+	for _, w := range sc.streamSendWindow {
+		w.Close()
+	}
+}
+
+// replenishWindow is called once a RequestFrame has been fully handled.
+// It hands the consumed bytes back to both the stream and connection
+// receive windows and tells the peer about it with a WINDOW_UPDATE
+// frame so it can resume sending.
+func (sc *serveconn) replenishWindow(frame *RequestFrame) {
+	n := int32(len(frame.Payload))
+	if n <= 0 {
+		return
+	}
+	sc.connRecvWindow.Increase(n)
+	sc.recvWindowFor(frame.RequestID).Increase(n)
+
+	writer := sc.GetWriter()
+	writer.StartWrite(frame.RequestID, 0, WindowUpdateFlag)
+	writer.WriteBytes(encodeWindowUpdate(n))
+	if err := writer.EndWrite(); err != nil {
+		logError("send window update", err, sc.rwc.RemoteAddr().String(), frame.RequestID)
+	}
 }
 
 // SetID sets id for serveconn
@@ -201,6 +405,15 @@ func (sc *serveconn) GetWriter() FrameWriter {
 	return newFrameWriter(sc.ctx, sc.writeFrameCh)
 }
 
+// AdjustStreamPriority changes the relative write priority requestID's
+// frames get from the connection's WriteScheduler; see WriteScheduler's
+// AdjustStream for the weight semantics. Handlers reach this through the
+// *serveconn stored in ctx's ConnectionInfoKey value, the same way they
+// reach Server/SetID.
+func (sc *serveconn) AdjustStreamPriority(requestID uint64, weight uint8) {
+	sc.scheduler.AdjustStream(requestID, weight)
+}
+
 // ErrInvalidPacket when packet invalid
 var ErrInvalidPacket = errors.New("invalid packet")
 
@@ -239,8 +452,43 @@ func (sc *serveconn) readFrames() (err error) {
 		if err != nil {
 			return err
 		}
+		frame := (*RequestFrame)(req)
+		sc.touchActivity()
+
+		if frame.Flags.IsPing() {
+			sc.sendPong(decodeNonce(frame.Payload))
+			continue
+		}
+		if frame.Flags.IsPong() {
+			sc.resolvePong(decodeNonce(frame.Payload))
+			continue
+		}
+
+		if frame.Flags.IsWindowUpdate() {
+			n := decodeWindowUpdate(frame.Payload)
+			sc.connSendWindow.Increase(n)
+			sc.sendWindowFor(frame.RequestID).Increase(n)
+			continue
+		}
+
+		if n := int32(len(frame.Payload)); n > 0 {
+			if err := sc.connRecvWindow.Consume(n); err != nil {
+				sc.rstStream(frame.RequestID, "flow control window exceeded")
+				continue
+			}
+			if err := sc.recvWindowFor(frame.RequestID).Consume(n); err != nil {
+				// The stream is being dropped, not the whole
+				// connection: give back the connection-level credit
+				// those bytes just consumed, or every stream-level
+				// violation permanently shrinks the connection window.
+				sc.connRecvWindow.Increase(n)
+				sc.rstStream(frame.RequestID, "flow control window exceeded")
+				continue
+			}
+		}
+
 		select {
-		case sc.readFrameCh <- readFrameResult{f: (*RequestFrame)(req), readMore: gateDone}:
+		case sc.readFrameCh <- readFrameResult{f: frame, readMore: gateDone}:
 		case <-ctx.Done():
 			return nil
 		}
@@ -254,43 +502,95 @@ func (sc *serveconn) readFrames() (err error) {
 
 }
 
+// scheduleWrites drains writeFrameCh, which is the channel FrameWriter
+// sends into, and feeds each request to the connection's WriteScheduler.
+// It is the only writer of sc.scheduler, keeping Push/Pop decoupled from
+// the FrameWriter API.
+func (sc *serveconn) scheduleWrites() {
+	ctx := sc.ctx
+	for {
+		select {
+		case res := <-sc.writeFrameCh:
+			sc.scheduler.Push(&res)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (sc *serveconn) writeFrames(timeout int) (err error) {
 
 	ctx := sc.ctx
 	writer := NewWriterWithTimeout(ctx, sc.rwc, timeout)
+
+	var wake <-chan struct{}  // set if the scheduler supports parking
+	var poll <-chan time.Time // otherwise Pop is polled on this ticker
+	if s, ok := sc.scheduler.(readySignaler); ok {
+		wake = s.ready()
+	} else {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
 	for {
-		select {
-		case res := <-sc.writeFrameCh:
-			dfw := res.dfw
-			flags := dfw.Flags()
-			requestID := dfw.RequestID()
-
-			if flags.IsRst() {
-				s := sc.cs.GetStream(requestID, flags)
-				if s == nil {
-					res.result <- ErrRstNonExistingStream
-					break
-				}
-				// for rst frame, AddOutFrame returns false when no need to send the frame
-				if !s.AddOutFrame(requestID, flags) {
-					res.result <- nil
-					break
-				}
-			} else if !flags.IsPush() { // skip stream logic if PushFlag set
-				s := sc.cs.CreateOrGetStream(sc.ctx, requestID, flags)
-				if !s.AddOutFrame(requestID, flags) {
-					res.result <- ErrWriteAfterCloseSelf
-					break
+		res, ok := sc.scheduler.Pop()
+		if !ok {
+			select {
+			case <-wake:
+			case <-poll:
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+
+		dfw := res.dfw
+		flags := dfw.Flags()
+		requestID := dfw.RequestID()
+
+		if !flowControlExempt(flags) {
+			if n := int32(len(dfw.GetWbuf())); n > 0 {
+				// park the write until the peer's advertised window
+				// has room; Increase is called as WINDOW_UPDATEs
+				// arrive in readFrames.
+				if !acquireWindow(sc.connSendWindow, n) || !acquireWindow(sc.sendWindowFor(requestID), n) {
+					res.result <- ctx.Err()
+					continue
 				}
 			}
+		}
 
-			_, err := writer.Write(dfw.GetWbuf())
-			if err != nil {
-				logError("serveconn Write", err)
-				sc.Close()
+		if flags.IsRst() {
+			s := sc.cs.GetStream(requestID, flags)
+			if s == nil {
+				res.result <- ErrRstNonExistingStream
+				continue
 			}
-			res.result <- err
-		case <-ctx.Done():
+			// for rst frame, AddOutFrame returns false when no need to send the frame
+			if !s.AddOutFrame(requestID, flags) {
+				res.result <- nil
+				continue
+			}
+			sc.scheduler.CloseStream(requestID)
+		} else if !flags.IsPush() { // skip stream logic if PushFlag set
+			s := sc.cs.CreateOrGetStream(sc.ctx, requestID, flags)
+			if !s.AddOutFrame(requestID, flags) {
+				res.result <- ErrWriteAfterCloseSelf
+				continue
+			}
+		}
+
+		_, err := writer.Write(dfw.GetWbuf())
+		if err != nil {
+			logError("serveconn Write", err)
+			sc.Close()
+		} else {
+			sc.touchActivity()
+		}
+		res.result <- err
+
+		if ctx.Err() != nil {
 			return nil
 		}
 	}
@@ -315,6 +615,14 @@ func (sc *serveconn) closeUntracked() error {
 	}
 	sc.cancelCtx()
 
+	if sc.connSendWindow != nil {
+		sc.connSendWindow.Close()
+	}
+	if sc.connRecvWindow != nil {
+		sc.connRecvWindow.Close()
+	}
+	sc.closeStreamWindows()
+
 	for _, f := range sc.closeNotify {
 		f()
 	}
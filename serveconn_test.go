@@ -0,0 +1,38 @@
+package qrpc
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestServeconnDrainCompleteWaitsForInFlight(t *testing.T) {
+	sc := &serveconn{}
+
+	if sc.drainComplete() {
+		t.Fatal("drainComplete true before draining starts")
+	}
+
+	sc.inFlight = 2
+	atomic.StoreInt32(&sc.draining, 1)
+	if sc.drainComplete() {
+		t.Fatal("drainComplete true with requests still in flight")
+	}
+
+	sc.inFlight = 1
+	if sc.drainComplete() {
+		t.Fatal("drainComplete true with one request still in flight")
+	}
+
+	sc.inFlight = 0
+	if !sc.drainComplete() {
+		t.Fatal("drainComplete false once draining and inFlight reaches 0")
+	}
+}
+
+func TestServeconnStreamCountReflectsInFlight(t *testing.T) {
+	sc := &serveconn{inFlight: 2}
+	atomic.AddInt32(&sc.inFlight, -1)
+	if got := sc.StreamCount(); got != 1 {
+		t.Fatalf("StreamCount() = %d, want 1", got)
+	}
+}
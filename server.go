@@ -35,13 +35,22 @@ func (f HandlerFunc) ServeQRPC(w FrameWriter, r *RequestFrame) {
 
 // ServeMux is qrpc request multiplexer.
 type ServeMux struct {
-	mu sync.RWMutex
-	m  map[Cmd]Handler
+	mu           sync.RWMutex
+	m            map[Cmd]Handler
+	interceptors []UnaryInterceptor
 }
 
 // NewServeMux allocates and returns a new ServeMux.
 func NewServeMux() *ServeMux { return new(ServeMux) }
 
+// Use appends interceptors that run, in order, around every Handle'd
+// handler dispatched through this mux, independent of any interceptors
+// configured on the ServerBinding. Use is not safe to call concurrently
+// with ServeQRPC; register interceptors before the mux starts serving.
+func (mux *ServeMux) Use(interceptors ...UnaryInterceptor) {
+	mux.interceptors = append(mux.interceptors, interceptors...)
+}
+
 // HandleFunc registers the handler function for the given pattern.
 func (mux *ServeMux) HandleFunc(cmd Cmd, handler func(FrameWriter, *RequestFrame)) {
 	mux.Handle(cmd, HandlerFunc(handler))
@@ -71,11 +80,15 @@ func (mux *ServeMux) Handle(cmd Cmd, handler Handler) {
 func (mux *ServeMux) ServeQRPC(w FrameWriter, r *RequestFrame) {
 	mux.mu.RLock()
 	h, ok := mux.m[r.Cmd]
+	ics := mux.interceptors
+	mux.mu.RUnlock()
 	if !ok {
 		// TODO error response
 		return
 	}
-	mux.mu.RUnlock()
+	if len(ics) > 0 {
+		h = chainUnaryInterceptors(context.Background(), ics, h)
+	}
 	h.ServeQRPC(w, r)
 }
 
@@ -94,6 +107,8 @@ type Server struct {
 	wg sync.WaitGroup // wait group for goroutines
 
 	pushID uint64
+
+	onShutdown []func()
 }
 
 // NewServer creates a server
@@ -112,7 +127,7 @@ func (srv *Server) ListenAndServe() error {
 	for idx, binding := range srv.bindings {
 		ln, err := net.Listen("tcp", binding.Addr)
 		if err != nil {
-			srv.Shutdown()
+			srv.Shutdown(context.Background())
 			return err
 		}
 
@@ -148,8 +163,11 @@ func (srv *Server) serve(l tcpKeepAliveListener, idx int) error {
 	srv.trackListener(l, true)
 	defer srv.trackListener(l, false)
 
-	serveCtx, cancelFunc := context.WithCancel(context.Background())
-	defer cancelFunc()
+	// Connections get their own long-lived context, independent of this
+	// listener's accept loop, so that Shutdown can drain them in place
+	// instead of cancelling them the instant the listener stops
+	// accepting new connections.
+	connCtx := context.Background()
 	for {
 		l.SetDeadline(time.Now().Add(defaultAcceptTimeout))
 		rw, e := l.AcceptTCP()
@@ -182,7 +200,7 @@ func (srv *Server) serve(l tcpKeepAliveListener, idx int) error {
 		c := srv.newConn(rw, idx)
 
 		goFunc(&srv.wg, func() {
-			c.serve(serveCtx)
+			c.serve(connCtx)
 		})
 	}
 }
@@ -266,21 +284,104 @@ func (srv *Server) logf(format string, args ...interface{}) {
 
 var shutdownPollInterval = 500 * time.Millisecond
 
-// Shutdown gracefully shutdown the server
-func (srv *Server) Shutdown() error {
+// RegisterOnShutdown registers f to be called when Shutdown is invoked,
+// after new connections have stopped being accepted. Multiple calls to
+// RegisterOnShutdown will register multiple functions, called in an
+// unspecified order, each on its own goroutine; f should not assume it
+// runs before or after draining finishes.
+func (srv *Server) RegisterOnShutdown(f func()) {
+	srv.mu.Lock()
+	srv.onShutdown = append(srv.onShutdown, f)
+	srv.mu.Unlock()
+}
+
+// Stats returns a point-in-time snapshot of the server's load, useful
+// for observing drain progress after calling Shutdown.
+func (srv *Server) Stats() ServerStats {
+	var conns, streams int
+	for idx := range srv.activeConn {
+		srv.activeConn[idx].Range(func(key, _ interface{}) bool {
+			conns++
+			streams += key.(*serveconn).StreamCount()
+			return true
+		})
+	}
+	return ServerStats{ActiveConnections: conns, ActiveStreams: streams}
+}
+
+// Shutdown gracefully shuts down the server: it stops accepting new
+// connections, tells every active connection to stop admitting new
+// RequestIDs while letting handlers already dispatched finish and their
+// responses flush, and sends each a GOAWAY-style frame naming the
+// highest RequestID it processed so the peer can retry anything after
+// that elsewhere. It hard-closes any connection still open once ctx is
+// done. Shutdown does not return until every connection's serve
+// goroutine - and so its Close/closeNotify teardown - has actually
+// finished, not just until it's no longer in activeConn.
+func (srv *Server) Shutdown(ctx context.Context) error {
 
 	srv.mu.Lock()
 	lnerr := srv.closeListenersLocked()
+	srv.mu.Unlock()
 	if lnerr != nil {
 		return lnerr
 	}
-	srv.mu.Unlock()
 
 	close(srv.doneChan)
 
+	for _, f := range srv.onShutdown {
+		go f()
+	}
+
+	srv.drainActiveConns()
+
+	err := srv.waitDrained(ctx)
 	srv.wg.Wait()
+	return err
+}
 
-	return nil
+// waitDrained polls until no connection is active or ctx is done,
+// hard-closing every remaining connection in the latter case.
+func (srv *Server) waitDrained(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.Stats().ActiveConnections == 0 {
+			return ctx.Err()
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			srv.closeActiveConns()
+			return ctx.Err()
+		}
+	}
+}
+
+// drainActiveConns marks every active connection as draining and sends
+// it a GOAWAY frame; it does not wait for them to close.
+func (srv *Server) drainActiveConns() {
+	for idx := range srv.activeConn {
+		srv.activeConn[idx].Range(func(key, _ interface{}) bool {
+			sc := key.(*serveconn)
+			sc.startDraining()
+			if err := sc.sendGoAway(sc.lastProcessedRequestID()); err != nil {
+				logError("send goaway", err, sc.rwc.RemoteAddr().String())
+			}
+			return true
+		})
+	}
+}
+
+// closeActiveConns hard-closes every connection still tracked, used once
+// the Shutdown caller's context expires.
+func (srv *Server) closeActiveConns() {
+	for idx := range srv.activeConn {
+		srv.activeConn[idx].Range(func(key, _ interface{}) bool {
+			key.(*serveconn).Close()
+			return true
+		})
+	}
 }
 
 // PushFrame pushes a frame to specified connection
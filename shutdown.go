@@ -0,0 +1,23 @@
+package qrpc
+
+// GoAwayFlag marks a control frame sent by a draining server telling the
+// peer the highest RequestID it has admitted for processing, mirroring
+// HTTP/2's GOAWAY. The payload is the big-endian uint64 RequestID
+// produced by encodeNonce. Requests the peer already sent above that id
+// were never dispatched and can safely be retried on another
+// connection.
+const GoAwayFlag PacketFlag = 1 << 3
+
+// IsGoAway reports whether flags marks a GOAWAY frame.
+func (flags PacketFlag) IsGoAway() bool { return flags&GoAwayFlag != 0 }
+
+// ServerStats is a point-in-time snapshot of a Server's load, returned
+// by Server.Stats() for observability during a drain.
+type ServerStats struct {
+	// ActiveConnections is the number of connections currently tracked
+	// across all bindings.
+	ActiveConnections int
+	// ActiveStreams is the number of RequestFrames currently dispatched
+	// to a handler across all tracked connections.
+	ActiveStreams int
+}